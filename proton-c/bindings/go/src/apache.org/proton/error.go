@@ -23,7 +23,11 @@ package proton
 import "C"
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"runtime"
+	"sync"
 )
 
 // errorCode is an error code returned by proton C.
@@ -31,14 +35,14 @@ type errorCode int
 
 const (
 	errEOS         errorCode = C.PN_EOS
-	errError                 = C.PN_ERR
-	errOverflow              = C.PN_OVERFLOW
-	errUnderflow             = C.PN_UNDERFLOW
-	errState                 = C.PN_STATE_ERR
-	errArgument              = C.PN_ARG_ERR
-	errTimeout               = C.PN_TIMEOUT
-	errInterrupted           = C.PN_INTR
-	errInProgress            = C.PN_INPROGRESS
+	errError       errorCode = C.PN_ERR
+	errOverflow    errorCode = C.PN_OVERFLOW
+	errUnderflow   errorCode = C.PN_UNDERFLOW
+	errState       errorCode = C.PN_STATE_ERR
+	errArgument    errorCode = C.PN_ARG_ERR
+	errTimeout     errorCode = C.PN_TIMEOUT
+	errInterrupted errorCode = C.PN_INTR
+	errInProgress  errorCode = C.PN_INPROGRESS
 )
 
 // String gives a brief description of an errorCode.
@@ -71,12 +75,277 @@ func (code errorCode) Error() string {
 	return fmt.Sprintf("proton: %v", code)
 }
 
-// errorf formats an error message with a proton: prefix.
-func errorf(format string, a ...interface{}) error {
-	return fmt.Errorf("proton: %v", fmt.Sprintf(format, a...))
+// Error is the error type returned by the proton package. It carries the
+// underlying proton errorCode, an optional AMQP condition symbol describing
+// the failure (e.g. "amqp:internal-error", "amqp:link:detach-forced") and an
+// optional wrapped cause, so callers can use errors.Is/errors.As to test for
+// specific proton failures rather than matching on error strings.
+type Error struct {
+	code      errorCode
+	msg       string
+	condition string
+	info      map[string]interface{}
+	cause     error
+	stack     []uintptr
 }
 
-// errorf2 formats an error message with a proton: prefix and an inner error message.
-func errorf2(err error, format string, a ...interface{}) error {
-	return fmt.Errorf("proton: %v: %v", fmt.Sprintf(format, a...), err)
+// maxStackDepth bounds the number of call frames captured with an Error.
+const maxStackDepth = 32
+
+// callers captures the stack of the caller of the proton function that
+// constructs an Error (errorf, errorf2, Wrap, Recover), skipping the
+// runtime.Callers/callers frames themselves.
+func callers() []uintptr {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(3, pcs[:])
+	return pcs[:n]
+}
+
+// frames resolves the captured stack into runtime.Frame values, lazily
+// via runtime.CallersFrames as recommended for long-lived PC slices.
+func (e *Error) frames() []runtime.Frame {
+	if len(e.stack) == 0 {
+		return nil
+	}
+	frames := runtime.CallersFrames(e.stack)
+	result := make([]runtime.Frame, 0, len(e.stack))
+	for {
+		frame, more := frames.Next()
+		result = append(result, frame)
+		if !more {
+			break
+		}
+	}
+	return result
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("proton: %v: %v", e.msg, e.cause)
+	}
+	return fmt.Sprintf("proton: %v", e.msg)
+}
+
+// Format implements fmt.Formatter. %s and %v print the same text as Error()
+// ("proton: <msg>", followed by ": <cause>" when there is a cause), %+v
+// appends the resolved call stack (function and file:line per frame), and
+// %#v emits a JSON object with code/msg/cause/stack fields
+// suitable for structured logging.
+func (e *Error) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('#'):
+		fmt.Fprint(f, e.goString())
+	case verb == 'v' && f.Flag('+'):
+		fmt.Fprint(f, e.Error())
+		for _, fr := range e.frames() {
+			fmt.Fprintf(f, "\n\t%s\n\t\t%s:%d", fr.Function, fr.File, fr.Line)
+		}
+	case verb == 'v', verb == 's':
+		fmt.Fprint(f, e.Error())
+	default:
+		fmt.Fprintf(f, "%%!%c(*proton.Error=%s)", verb, e.Error())
+	}
+}
+
+// goString renders e as a JSON object, used by the %#v Format verb.
+func (e *Error) goString() string {
+	cause := ""
+	if e.cause != nil {
+		cause = e.cause.Error()
+	}
+	frames := e.frames()
+	stack := make([]string, 0, len(frames))
+	for _, fr := range frames {
+		stack = append(stack, fmt.Sprintf("%s %s:%d", fr.Function, fr.File, fr.Line))
+	}
+	b, err := json.Marshal(struct {
+		Code  int      `json:"code"`
+		Msg   string   `json:"msg"`
+		Cause string   `json:"cause,omitempty"`
+		Stack []string `json:"stack,omitempty"`
+	}{int(e.code), e.msg, cause, stack})
+	if err != nil {
+		return e.Error()
+	}
+	return string(b)
+}
+
+// Unwrap returns the wrapped cause, if any, so errors.Is/errors.As can walk
+// the chain.
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether target is a *Error with the same errorCode, so callers
+// can write errors.Is(err, proton.ErrTimeout). This is the supported way to
+// discriminate proton errors; errorCode itself is unexported and has no
+// exported accessor, since every value a caller could compare it against
+// (errTimeout, errState, ...) is unexported too.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return t.code == e.code
+}
+
+// Sentinel errors for the proton error codes, for use with errors.Is.
+var (
+	ErrEOS         = &Error{code: errEOS, msg: errEOS.String()}
+	ErrError       = &Error{code: errError, msg: errError.String()}
+	ErrOverflow    = &Error{code: errOverflow, msg: errOverflow.String()}
+	ErrUnderflow   = &Error{code: errUnderflow, msg: errUnderflow.String()}
+	ErrState       = &Error{code: errState, msg: errState.String()}
+	ErrArgument    = &Error{code: errArgument, msg: errArgument.String()}
+	ErrTimeout     = &Error{code: errTimeout, msg: errTimeout.String()}
+	ErrInterrupted = &Error{code: errInterrupted, msg: errInterrupted.String()}
+	ErrInProgress  = &Error{code: errInProgress, msg: errInProgress.String()}
+)
+
+// errorf formats an error message with a proton: prefix, capturing the
+// current call stack.
+func errorf(code errorCode, format string, a ...interface{}) error {
+	return &Error{code: code, msg: fmt.Sprintf(format, a...), stack: callers()}
+}
+
+// errorf2 formats an error message with a proton: prefix and an inner error
+// message, capturing the current call stack.
+func errorf2(code errorCode, err error, format string, a ...interface{}) error {
+	return &Error{code: code, msg: fmt.Sprintf(format, a...), cause: err, stack: callers()}
+}
+
+// Wrap annotates err with an additional message, for use as the err return
+// propagates up the call stack. If err is already a *Error, Wrap pushes the
+// new message onto its chain and keeps the original stack trace, which
+// points at the root cause rather than each intermediate wrap site;
+// otherwise it captures a fresh stack.
+func Wrap(err error, format string, a ...interface{}) error {
+	msg := fmt.Sprintf(format, a...)
+	if pe, ok := err.(*Error); ok {
+		return &Error{code: pe.code, msg: msg, cause: err, stack: pe.stack}
+	}
+	return &Error{code: errError, msg: msg, cause: err, stack: callers()}
+}
+
+// ErrorCondition is the Go representation of an AMQP error condition, as
+// carried in the condition field of a link/session/connection close frame.
+type ErrorCondition struct {
+	Name        string
+	Description string
+	Info        map[string]interface{}
+}
+
+// defaultCondition is used for Go errors with no more specific mapping.
+const defaultCondition = "amqp:internal-error"
+
+// conditionRegistryMu guards conditionRegistry, which RegisterErrorCondition
+// can write concurrently with ToCondition/FromCondition reading it while
+// error handling runs on a live connection.
+var conditionRegistryMu sync.RWMutex
+
+// conditionRegistry maps sentinel errors to the AMQP condition symbol a peer
+// should see in their place. Keyed by error value rather than errorCode so
+// that RegisterErrorCondition also works for arbitrary application errors,
+// not just *Error values.
+var conditionRegistry = map[error]string{
+	ErrTimeout:  "amqp:resource-limit-exceeded",
+	ErrState:    "amqp:illegal-state",
+	ErrArgument: "amqp:decode-error",
+	ErrEOS:      "amqp:connection:forced",
+}
+
+// RegisterErrorCondition records the AMQP condition symbol that ToCondition
+// should report for errors matching target (as tested by errors.Is),
+// overriding any previous registration for that error.
+func RegisterErrorCondition(target error, condition string) {
+	conditionRegistryMu.Lock()
+	defer conditionRegistryMu.Unlock()
+	conditionRegistry[target] = condition
+}
+
+// ToCondition converts err into the AMQP ErrorCondition a peer should see on
+// a link/session/connection close. If err is a *Error carrying a condition
+// recovered from FromCondition, that condition is returned unchanged;
+// otherwise conditionRegistry is consulted, falling back to
+// defaultCondition for unrecognized errors.
+func ToCondition(err error) ErrorCondition {
+	if err == nil {
+		return ErrorCondition{}
+	}
+	var info map[string]interface{}
+	if pe, ok := err.(*Error); ok {
+		if pe.condition != "" {
+			return ErrorCondition{Name: pe.condition, Description: err.Error(), Info: pe.info}
+		}
+		info = pe.info
+	}
+	conditionRegistryMu.RLock()
+	defer conditionRegistryMu.RUnlock()
+	for target, condition := range conditionRegistry {
+		if errors.Is(err, target) {
+			return ErrorCondition{Name: condition, Description: err.Error(), Info: info}
+		}
+	}
+	return ErrorCondition{Name: defaultCondition, Description: err.Error(), Info: info}
+}
+
+// FromCondition converts an AMQP ErrorCondition received from a peer back
+// into a Go error, recovering one of the sentinel errors in conditionRegistry
+// where possible, or a generic *Error otherwise. A zero-value cond (no
+// condition on the frame) maps back to a nil error.
+func FromCondition(cond ErrorCondition) error {
+	if cond.Name == "" {
+		return nil
+	}
+	conditionRegistryMu.RLock()
+	defer conditionRegistryMu.RUnlock()
+	for target, condition := range conditionRegistry {
+		if condition == cond.Name {
+			if pe, ok := target.(*Error); ok {
+				return &Error{code: pe.code, msg: cond.Description, condition: cond.Name, info: cond.Info, stack: callers()}
+			}
+		}
+	}
+	return &Error{code: errError, msg: cond.Description, condition: cond.Name, info: cond.Info, stack: callers()}
+}
+
+// Recover recovers a panic at the point it is deferred and, if one occurred,
+// wraps it into a *Error (preserving the original error and its stack, if
+// the panic value already implements error) and assigns it to *errp, but
+// only if *errp is currently nil so an existing error is never overwritten.
+// Use at the top of every exported callback and public API entry that
+// crosses a cgo boundary, where an unrecovered Go panic would crash the
+// process:
+//
+//	func (h *handler) onMessage(e Event) (err error) {
+//		defer Recover(&err)
+//		...
+//	}
+func Recover(errp *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if *errp != nil {
+		return
+	}
+	if err, ok := r.(error); ok {
+		*errp = &Error{code: errError, msg: fmt.Sprintf("recovered panic: %v", err), cause: err, stack: callers()}
+		return
+	}
+	*errp = &Error{code: errError, msg: fmt.Sprintf("recovered panic: %v", r), stack: callers()}
+}
+
+// Must panics with a *Error if err is not nil, so internal code can use
+// panic-based control flow for conditions that should never happen while a
+// deferred Recover still surfaces a typed *Error to the caller.
+func Must(err error) {
+	if err == nil {
+		return
+	}
+	if pe, ok := err.(*Error); ok {
+		panic(pe)
+	}
+	panic(&Error{code: errError, msg: err.Error(), cause: err, stack: callers()})
 }
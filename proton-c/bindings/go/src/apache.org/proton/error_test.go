@@ -0,0 +1,145 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package proton
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestErrorIs(t *testing.T) {
+	err := errorf(errTimeout, "deadline exceeded")
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("errors.Is(err, ErrTimeout) = false, want true")
+	}
+	if errors.Is(err, ErrState) {
+		t.Errorf("errors.Is(err, ErrState) = true, want false")
+	}
+}
+
+func TestErrorUnwrap(t *testing.T) {
+	cause := errorf(errArgument, "bad frame")
+	err := errorf2(errState, cause, "handling frame")
+	if !errors.Is(err, ErrState) {
+		t.Errorf("errors.Is(err, ErrState) = false, want true")
+	}
+	if !errors.Is(err, ErrArgument) {
+		t.Errorf("errors.Is(err, ErrArgument) = false, want true: Unwrap should expose the cause")
+	}
+}
+
+func TestConditionRoundTrip(t *testing.T) {
+	cond := ToCondition(ErrTimeout)
+	if cond.Name != "amqp:resource-limit-exceeded" {
+		t.Fatalf("ToCondition(ErrTimeout).Name = %q, want amqp:resource-limit-exceeded", cond.Name)
+	}
+
+	err := FromCondition(cond)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("errors.Is(FromCondition(ToCondition(ErrTimeout)), ErrTimeout) = false, want true")
+	}
+
+	back := ToCondition(err)
+	if back.Name != cond.Name {
+		t.Errorf("ToCondition(FromCondition(cond)).Name = %q, want %q", back.Name, cond.Name)
+	}
+}
+
+func TestConditionRoundTripInfo(t *testing.T) {
+	cond := ErrorCondition{Name: "amqp:not-found", Description: "no such node", Info: map[string]interface{}{"address": "q1"}}
+	err := FromCondition(cond)
+	back := ToCondition(err)
+	if back.Name != cond.Name {
+		t.Errorf("ToCondition(err).Name = %q, want %q", back.Name, cond.Name)
+	}
+	if back.Info["address"] != "q1" {
+		t.Errorf("ToCondition(err).Info = %v, want to retain Info from FromCondition", back.Info)
+	}
+}
+
+func TestFromConditionEmpty(t *testing.T) {
+	if err := FromCondition(ErrorCondition{}); err != nil {
+		t.Errorf("FromCondition(ErrorCondition{}) = %v, want nil", err)
+	}
+}
+
+func TestErrorFormat(t *testing.T) {
+	err := errorf(errTimeout, "deadline exceeded")
+
+	if got, want := fmt.Sprintf("%v", err), "proton: deadline exceeded"; got != want {
+		t.Errorf("%%v = %q, want %q", got, want)
+	}
+
+	plus := fmt.Sprintf("%+v", err)
+	if !strings.HasPrefix(plus, "proton: deadline exceeded") {
+		t.Errorf("%%+v = %q, want prefix %q", plus, "proton: deadline exceeded")
+	}
+	if !strings.Contains(plus, "TestErrorFormat") {
+		t.Errorf("%%+v = %q, want it to mention the calling test function", plus)
+	}
+
+	sharp := fmt.Sprintf("%#v", err)
+	var parsed map[string]interface{}
+	if jerr := json.Unmarshal([]byte(sharp), &parsed); jerr != nil {
+		t.Fatalf("%%#v produced invalid JSON %q: %v", sharp, jerr)
+	}
+	if parsed["msg"] != "deadline exceeded" {
+		t.Errorf("%%#v msg = %v, want %q", parsed["msg"], "deadline exceeded")
+	}
+}
+
+func TestRecoverKeepsExistingError(t *testing.T) {
+	first := errorf(errState, "already failed")
+	got := first
+	func() {
+		defer Recover(&got)
+		panic("boom")
+	}()
+	if got != first {
+		t.Errorf("Recover overwrote an existing *errp: got %v, want %v", got, first)
+	}
+}
+
+func TestRecoverSetsErrorFromPanic(t *testing.T) {
+	var got error
+	func() {
+		defer Recover(&got)
+		panic("boom")
+	}()
+	if got == nil {
+		t.Fatal("Recover left *errp nil after a panic")
+	}
+	if !strings.Contains(got.Error(), "boom") {
+		t.Errorf("Recover error = %v, want it to mention the panic value", got)
+	}
+}
+
+func TestRecoverNoPanic(t *testing.T) {
+	var got error
+	func() {
+		defer Recover(&got)
+	}()
+	if got != nil {
+		t.Errorf("Recover set *errp = %v with no panic, want nil", got)
+	}
+}